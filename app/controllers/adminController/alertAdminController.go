@@ -0,0 +1,69 @@
+package adminController
+
+import (
+	"strconv"
+
+	"wejh-go/app/apiException"
+	"wejh-go/app/models"
+	"wejh-go/app/services/alertServices"
+	"wejh-go/app/utils"
+	"wejh-go/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+type channelConfigReq struct {
+	Channel string `json:"channel" binding:"required"`
+	Config  string `json:"config" binding:"required"`
+	Enabled bool   `json:"enabled"`
+}
+
+// SetNotifierChannelConfig 新增或更新一个通知渠道的凭据配置
+func SetNotifierChannelConfig(c *gin.Context) {
+	var req channelConfigReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+
+	var cfg models.NotifierChannelConfig
+	err := config.DB.Where("channel = ?", req.Channel).First(&cfg).Error
+	cfg.Channel = req.Channel
+	cfg.Config = req.Config
+	cfg.Enabled = req.Enabled
+	if err != nil {
+		err = config.DB.Create(&cfg).Error
+	} else {
+		err = config.DB.Save(&cfg).Error
+	}
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+	utils.JsonSuccessResponse(c, cfg)
+}
+
+// ListLowBatteryAlertSubscriptions 列出所有用户的低电量提醒订阅
+func ListLowBatteryAlertSubscriptions(c *gin.Context) {
+	var subs []models.LowBatteryAlertSubscription
+	if err := config.DB.Find(&subs).Error; err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+	utils.JsonSuccessResponse(c, subs)
+}
+
+// TriggerLowBatteryAlert 手动触发一次指定订阅的提醒检查
+func TriggerLowBatteryAlert(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	if err := alertServices.DispatchNow(uint(id)); err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+	utils.JsonSuccessResponse(c, nil)
+}
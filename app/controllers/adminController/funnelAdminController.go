@@ -0,0 +1,69 @@
+package adminController
+
+import (
+	"wejh-go/app/apiException"
+	"wejh-go/app/utils"
+	"wejh-go/app/utils/circuitBreaker"
+	"wejh-go/config/api/funnelApi"
+
+	"github.com/gin-gonic/gin"
+)
+
+type funnelHostReq struct {
+	Host      string `json:"host" binding:"required"`
+	LoginType string `json:"loginType" binding:"required"` // "zf" 或 "oauth"
+}
+
+type funnelHostStat struct {
+	circuitBreaker.HostStat
+	CircuitState string `json:"circuitState"`
+}
+
+// GetFunnelStats 返回各 funnel 节点当前的 ewmaLatency / inflight / successRate 滚动统计，
+// 以及对应的熔断器状态（closed / open / half_open）
+func GetFunnelStats(c *gin.Context) {
+	raw := circuitBreaker.CB.LB.Stats()
+	out := make(map[string][]funnelHostStat, len(raw))
+	for loginType, stats := range raw {
+		list := make([]funnelHostStat, 0, len(stats))
+		for _, s := range stats {
+			list = append(list, funnelHostStat{
+				HostStat:     s,
+				CircuitState: circuitBreaker.CB.State(s.Host, loginType).String(),
+			})
+		}
+		out[string(loginType)] = list
+	}
+	utils.JsonSuccessResponse(c, out)
+}
+
+// ReloadCircuitBreakerConfig 从 DB 重新加载熔断策略，无需重启进程
+func ReloadCircuitBreakerConfig(c *gin.Context) {
+	if err := circuitBreaker.CB.ReloadConfig(); err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+	utils.JsonSuccessResponse(c, nil)
+}
+
+// AddFunnelHost 在运行时添加一个 funnel 节点，无需重新部署
+func AddFunnelHost(c *gin.Context) {
+	var req funnelHostReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	circuitBreaker.CB.LB.Add(req.Host, funnelApi.LoginType(req.LoginType))
+	utils.JsonSuccessResponse(c, nil)
+}
+
+// RemoveFunnelHost 在运行时移除一个 funnel 节点
+func RemoveFunnelHost(c *gin.Context) {
+	var req funnelHostReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	circuitBreaker.CB.LB.Remove(req.Host, funnelApi.LoginType(req.LoginType))
+	utils.JsonSuccessResponse(c, nil)
+}
@@ -0,0 +1,37 @@
+package adminController
+
+import (
+	"wejh-go/app/apiException"
+	"wejh-go/app/services/rbacServices"
+	"wejh-go/app/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type assignRoleReq struct {
+	UserID uint   `json:"userId" binding:"required"`
+	Role   string `json:"role" binding:"required"`
+}
+
+// AssignRole 把某个用户加入一个角色（student / staff / admin / superadmin）
+func AssignRole(c *gin.Context) {
+	var req assignRoleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	if err := rbacServices.AssignRole(req.UserID, req.Role); err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+	utils.JsonSuccessResponse(c, nil)
+}
+
+// ReloadRbacPolicies 从 DB 重新加载 casbin 策略，改完权限配置后立即生效，无需重启
+func ReloadRbacPolicies(c *gin.Context) {
+	if err := rbacServices.Reload(); err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+	utils.JsonSuccessResponse(c, nil)
+}
@@ -2,8 +2,10 @@ package electricityController
 
 import (
 	"errors"
+	"strings"
 	"wejh-go/app/apiException"
 	"wejh-go/app/models"
+	"wejh-go/app/services/alertServices"
 	"wejh-go/app/services/sessionServices"
 	"wejh-go/app/services/yxyServices"
 	"wejh-go/app/utils"
@@ -12,17 +14,25 @@ import (
 )
 
 type recordForm struct {
-	Page   string `form:"page" json:"page"`
-	Campus string `form:"campus" json:"campus"`
+	Page    string `form:"page" json:"page"`
+	Campus  string `form:"campus" json:"campus"`
+	Refresh string `form:"refresh" json:"refresh"`
 }
 
 type CampusForm struct {
-	Campus string `form:"campus"`
+	Campus  string `form:"campus"`
+	Refresh string `form:"refresh"`
 }
 
 type SubscribeLowBatteryAlertReq struct {
-	Campus    string `json:"campus"`
-	Threshold int    `json:"threshold"`
+	Campus    string   `json:"campus"`
+	Threshold int      `json:"threshold"`
+	Channels  []string `json:"channels"`
+	// Email / WebhookURL / WeChatOpenID 是用户为自己订阅的渠道填写的投递目标，
+	// 未选用的渠道对应字段留空即可
+	Email        string `json:"email"`
+	WebhookURL   string `json:"webhookUrl"`
+	WeChatOpenID string `json:"wechatOpenId"`
 }
 
 // GetBalance 获取电费余额
@@ -45,7 +55,7 @@ func GetBalance(c *gin.Context) {
 	if postForm.Campus != "mgs" {
 		postForm.Campus = "zhpf"
 	}
-	balance, err := yxyServices.ElectricityBalance(user.YxyUid, postForm.Campus)
+	balance, err := yxyServices.GetBalanceCached(c.Request.Context(), user.YxyUid, postForm.Campus, postForm.Refresh == "1")
 	if errors.Is(err, apiException.NotBindCard) {
 		_ = yxyServices.Unbind(user.ID, user.YxyUid, true)
 		apiException.AbortWithError(c, err)
@@ -80,7 +90,8 @@ func GetRechargeRecords(c *gin.Context) {
 	if postForm.Campus != "mgs" {
 		postForm.Campus = "zhpf"
 	}
-	roomStrConcat, err := yxyServices.GetElecRoomStrConcat(user.YxyUid, postForm.Campus)
+	refresh := postForm.Refresh == "1"
+	roomStrConcat, err := yxyServices.GetRoomStrConcatCached(c.Request.Context(), user.YxyUid, postForm.Campus, refresh)
 	if errors.Is(err, apiException.NotBindCard) {
 		_ = yxyServices.Unbind(user.ID, user.YxyUid, true)
 		apiException.AbortWithError(c, err)
@@ -92,7 +103,7 @@ func GetRechargeRecords(c *gin.Context) {
 		apiException.AbortWithException(c, apiException.ServerError, err)
 		return
 	}
-	records, err := yxyServices.ElectricityRechargeRecords(user.YxyUid, postForm.Campus, postForm.Page, *roomStrConcat)
+	records, err := yxyServices.GetRechargeRecordsCached(c.Request.Context(), user.YxyUid, postForm.Campus, postForm.Page, *roomStrConcat, refresh)
 	if errors.Is(err, apiException.CampusMismatch) {
 		apiException.AbortWithError(c, err)
 		return
@@ -100,7 +111,7 @@ func GetRechargeRecords(c *gin.Context) {
 		apiException.AbortWithException(c, apiException.ServerError, err)
 		return
 	}
-	utils.JsonSuccessResponse(c, records.List)
+	utils.JsonSuccessResponse(c, records)
 }
 
 // GetConsumptionRecords 获取电费使用记录
@@ -122,7 +133,8 @@ func GetConsumptionRecords(c *gin.Context) {
 	if postForm.Campus != "mgs" {
 		postForm.Campus = "zhpf"
 	}
-	roomStrConcat, err := yxyServices.GetElecRoomStrConcat(user.YxyUid, postForm.Campus)
+	refresh := postForm.Refresh == "1"
+	roomStrConcat, err := yxyServices.GetRoomStrConcatCached(c.Request.Context(), user.YxyUid, postForm.Campus, refresh)
 	if errors.Is(err, apiException.NotBindCard) {
 		_ = yxyServices.Unbind(user.ID, user.YxyUid, true)
 		apiException.AbortWithError(c, err)
@@ -134,7 +146,7 @@ func GetConsumptionRecords(c *gin.Context) {
 		apiException.AbortWithException(c, apiException.ServerError, err)
 		return
 	}
-	records, err := yxyServices.GetElecConsumptionRecords(user.YxyUid, postForm.Campus, *roomStrConcat)
+	records, err := yxyServices.GetConsumptionRecordsCached(c.Request.Context(), user.YxyUid, postForm.Campus, *roomStrConcat, refresh)
 	if errors.Is(err, apiException.CampusMismatch) {
 		apiException.AbortWithError(c, err)
 		return
@@ -142,20 +154,14 @@ func GetConsumptionRecords(c *gin.Context) {
 		apiException.AbortWithException(c, apiException.ServerError, err)
 		return
 	}
-	utils.JsonSuccessResponse(c, records.List)
+	utils.JsonSuccessResponse(c, records)
 }
 
 func SubscribeLowBatteryAlert(c *gin.Context) {
-	// var req SubscribeLowBatteryAlertReq
-	// err := c.ShouldBindJSON(&req)
-	// if err != nil {
-	// 	apiException.AbortWithException(c, apiException.ParamError)
-	// 	return
-	// }
-	// 临时兼容用
-	req := SubscribeLowBatteryAlertReq{
-		Campus:    "zhpf",
-		Threshold: 20,
+	var req SubscribeLowBatteryAlertReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
 	}
 	user, err := sessionServices.GetUserSession(c)
 	if err != nil {
@@ -172,10 +178,25 @@ func SubscribeLowBatteryAlert(c *gin.Context) {
 	if req.Threshold <= 0 {
 		req.Threshold = 20
 	}
+	if len(req.Channels) == 0 {
+		req.Channels = []string{models.NotifierChannelWeChatOA}
+	}
+	if req.WebhookURL != "" {
+		if err := alertServices.ValidateWebhookURL(req.WebhookURL); err != nil {
+			apiException.AbortWithException(c, apiException.ParamError, err)
+			return
+		}
+	}
 	if err := yxyServices.SubscribeLowBatteryAlert(models.LowBatteryAlertSubscription{
-		UserID:    user.ID,
-		Campus:    req.Campus,
-		Threshold: req.Threshold,
+		UserID:       user.ID,
+		YxyUid:       user.YxyUid,
+		Campus:       req.Campus,
+		Threshold:    req.Threshold,
+		Channels:     strings.Join(req.Channels, ","),
+		Active:       true,
+		Email:        req.Email,
+		WebhookURL:   req.WebhookURL,
+		WeChatOpenID: req.WeChatOpenID,
 	}); err != nil {
 		apiException.AbortWithException(c, apiException.ServerError, err)
 		return
@@ -0,0 +1,43 @@
+// Package middleware 存放跨路由复用的 gin 中间件
+package middleware
+
+import (
+	"strconv"
+
+	"wejh-go/app/apiException"
+	"wejh-go/app/services/rbacServices"
+	"wejh-go/app/services/sessionServices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RBAC 基于 casbin 校验当前登录用户是否有权限访问该路由（sub=用户 ID，obj=请求路径，act=HTTP 方法），
+// 角色到路径的策略存放在 DB，可以通过 rbacServices.Reload 热更新，不需要重启
+func RBAC() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := sessionServices.GetUserSession(c)
+		if err != nil {
+			apiException.AbortWithException(c, apiException.NotLogin, err)
+			return
+		}
+
+		enforcer := rbacServices.Enforcer()
+		if enforcer == nil {
+			apiException.AbortWithException(c, apiException.ServerError, nil)
+			return
+		}
+
+		sub := strconv.FormatUint(uint64(user.ID), 10)
+		ok, err := enforcer.Enforce(sub, c.Request.URL.Path, c.Request.Method)
+		if err != nil {
+			apiException.AbortWithException(c, apiException.ServerError, err)
+			return
+		}
+		if !ok {
+			apiException.AbortWithException(c, apiException.Forbidden, nil)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+import "gorm.io/gorm"
+
+// CircuitBreakerConfig 持久化的熔断策略，Host 为空表示全局默认策略，
+// 运维可以针对单个 funnel 节点覆盖默认策略而不需要重新部署
+type CircuitBreakerConfig struct {
+	gorm.Model
+	Host             string  `json:"host" gorm:"index"`
+	LoginType        string  `json:"loginType"`
+	FailureThreshold float64 `json:"failureThreshold"` // 滑动窗口内失败率超过该值时 Open，如 0.5
+	MinSamples       int     `json:"minSamples"`       // 窗口内样本数达到该值才评估失败率
+	WindowSeconds    int     `json:"windowSeconds"`    // 滑动窗口长度
+	CooldownSeconds  int     `json:"cooldownSeconds"`  // Open 状态的初始冷却时长，重复 Open 按指数退避翻倍
+}
@@ -0,0 +1,22 @@
+package models
+
+import "gorm.io/gorm"
+
+// LowBatteryAlertSubscription 低电量提醒订阅
+type LowBatteryAlertSubscription struct {
+	gorm.Model
+	UserID    uint   `json:"userId" gorm:"index"`
+	YxyUid    string `json:"-"`
+	Campus    string `json:"campus"`
+	Threshold int    `json:"threshold"`
+	// Channels 用户选择的通知渠道，逗号分隔，取值见 NotifierChannel* 常量
+	Channels string `json:"channels"`
+	Active   bool   `json:"active" gorm:"default:true"`
+
+	// 以下为各渠道的个人投递目标：渠道凭据（SMTP 账号、AccessToken 等）由管理员
+	// 在 NotifierChannelConfig 里统一配置，但"发给谁"必须按用户区分，否则所有订阅了
+	// 同一渠道的用户会收到彼此的告警
+	Email        string `json:"email"`
+	WebhookURL   string `json:"webhookUrl"`
+	WeChatOpenID string `json:"wechatOpenId"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "gorm.io/gorm"
+
+// AutoMigrate 建好这个系列新增的四张表：NotificationLog / NotifierChannelConfig /
+// LowBatteryAlertSubscription / CircuitBreakerConfig。casbin_rule 由
+// gormadapter.NewAdapterByDB 自行建表，不需要在这里重复处理
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&NotificationLog{},
+		&NotifierChannelConfig{},
+		&LowBatteryAlertSubscription{},
+		&CircuitBreakerConfig{},
+	)
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NotificationLog 低电量提醒的每次投递记录，供审计和冷却判断使用
+type NotificationLog struct {
+	gorm.Model
+	SubscriptionID uint      `json:"subscriptionId" gorm:"index"`
+	UserID         uint      `json:"userId" gorm:"index"`
+	Channel        string    `json:"channel"`
+	Balance        float64   `json:"balance"`
+	Threshold      int       `json:"threshold"`
+	Success        bool      `json:"success"`
+	Error          string    `json:"error"`
+	SentAt         time.Time `json:"sentAt"`
+}
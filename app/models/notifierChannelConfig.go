@@ -0,0 +1,21 @@
+package models
+
+import "gorm.io/gorm"
+
+// 低电量提醒支持的通知渠道
+const (
+	NotifierChannelWeChatWork    = "wechat_work"
+	NotifierChannelWeChatOA      = "wechat_oa"
+	NotifierChannelEmail         = "email"
+	NotifierChannelWebhook       = "webhook"
+)
+
+// NotifierChannelConfig 由管理员配置的通知渠道凭据，Config 为该渠道专属的 JSON 配置
+// （如 WeChatWork 群机器人的 webhookUrl、SMTP 的账号密码、公众号的 accessToken 等）；
+// email / webhook / 公众号的投递目标来自各自的订阅，不在这里配置
+type NotifierChannelConfig struct {
+	gorm.Model
+	Channel string `json:"channel" gorm:"uniqueIndex"`
+	Config  string `json:"config"`
+	Enabled bool   `json:"enabled" gorm:"default:true"`
+}
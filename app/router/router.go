@@ -0,0 +1,86 @@
+// Package router 汇总各个 controller 的路由注册，以及进程启动时需要跑起来的后台任务
+package router
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	"wejh-go/app/controllers/adminController"
+	"wejh-go/app/controllers/yxyController/electricityController"
+	"wejh-go/app/middleware"
+	"wejh-go/app/models"
+	"wejh-go/app/services/alertServices"
+	"wejh-go/app/services/rbacServices"
+	"wejh-go/app/utils/metrics"
+	"wejh-go/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// superadminUserIDEnv 部署时通过这个环境变量指定第一个 superadmin 的用户 ID，
+// 否则挂了 RBAC 之后没有人能调用 /admin/rbac/assign 把自己加进管理员分组
+const superadminUserIDEnv = "SUPERADMIN_USER_ID"
+
+// Register 挂载电费相关的用户路由、需要 RBAC 校验的 admin 路由，以及 Prometheus 的 /metrics
+func Register(r *gin.Engine) {
+	r.GET("/metrics", metrics.GinHandler())
+
+	electricity := r.Group("/api/electricity")
+	{
+		electricity.GET("/balance", electricityController.GetBalance)
+		electricity.POST("/recharge-records", electricityController.GetRechargeRecords)
+		electricity.GET("/consumption-records", electricityController.GetConsumptionRecords)
+		electricity.POST("/low-battery-alert/subscribe", electricityController.SubscribeLowBatteryAlert)
+		electricity.GET("/low-battery-alert/subscription", electricityController.GetLowBatteryAlertSubscription)
+	}
+
+	admin := r.Group("/admin", middleware.RBAC())
+	{
+		admin.GET("/funnel/stats", adminController.GetFunnelStats)
+		admin.POST("/funnel/circuit-breaker/reload", adminController.ReloadCircuitBreakerConfig)
+		admin.POST("/funnel/hosts", adminController.AddFunnelHost)
+		admin.DELETE("/funnel/hosts", adminController.RemoveFunnelHost)
+
+		admin.POST("/rbac/assign", adminController.AssignRole)
+		admin.POST("/rbac/reload", adminController.ReloadRbacPolicies)
+
+		admin.POST("/alerts/channels", adminController.SetNotifierChannelConfig)
+		admin.GET("/alerts/subscriptions", adminController.ListLowBatteryAlertSubscriptions)
+		admin.POST("/alerts/subscriptions/:id/trigger", adminController.TriggerLowBatteryAlert)
+	}
+}
+
+// Bootstrap 做进程启动时一次性的初始化：建好这个系列新增的表、加载 casbin 策略
+// （没有则播种默认策略）、引导出第一个 superadmin、拉起低电量提醒轮询。ctx 取消时后台 worker 一并退出
+func Bootstrap(ctx context.Context) error {
+	if err := models.AutoMigrate(config.DB); err != nil {
+		return err
+	}
+	if err := rbacServices.Init(); err != nil {
+		return err
+	}
+	if err := rbacServices.SeedDefaultPolicies(); err != nil {
+		return err
+	}
+	if err := rbacServices.EnsureSuperadmin(superadminUserIDFromEnv()); err != nil {
+		return err
+	}
+
+	go alertServices.StartWorker(ctx)
+	return nil
+}
+
+// superadminUserIDFromEnv 读取 SUPERADMIN_USER_ID 环境变量，未配置或解析失败时返回 0
+// （EnsureSuperadmin 对 0 是空操作），避免部署时没配这个变量就直接启动失败
+func superadminUserIDFromEnv() uint {
+	raw := os.Getenv(superadminUserIDEnv)
+	if raw == "" {
+		return 0
+	}
+	id, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
@@ -0,0 +1,176 @@
+package alertServices
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"time"
+
+	"wejh-go/app/models"
+)
+
+// AlertPayload 一次低电量提醒需要投递的内容。
+// Email / WebhookURL / WeChatOpenID 是订阅时用户自己填写的投递目标（见
+// models.LowBatteryAlertSubscription），NotifierChannelConfig 里只存放渠道凭据
+// （SMTP 账号、AccessToken 等），不记录"发给谁"——这样同一个 email/webhook 渠道
+// 才能把告警分别送到每个订阅用户自己的地址，而不是全部发到同一个地方。
+type AlertPayload struct {
+	UserID       uint
+	Campus       string
+	Balance      float64
+	Threshold    int
+	Email        string
+	WebhookURL   string
+	WeChatOpenID string
+}
+
+// Notifier 单个通知渠道的投递能力，每种渠道一个实现
+type Notifier interface {
+	Channel() string
+	Send(ctx context.Context, payload AlertPayload) error
+}
+
+// newNotifier 根据渠道配置构造对应的 Notifier，Config 未知或禁用时返回 nil
+func newNotifier(cfg models.NotifierChannelConfig) Notifier {
+	if !cfg.Enabled {
+		return nil
+	}
+	switch cfg.Channel {
+	case models.NotifierChannelWeChatWork:
+		n := &wechatWorkNotifier{}
+		if err := json.Unmarshal([]byte(cfg.Config), n); err != nil {
+			return nil
+		}
+		return n
+	case models.NotifierChannelWeChatOA:
+		n := &wechatOANotifier{}
+		if err := json.Unmarshal([]byte(cfg.Config), n); err != nil {
+			return nil
+		}
+		return n
+	case models.NotifierChannelEmail:
+		n := &emailNotifier{}
+		if err := json.Unmarshal([]byte(cfg.Config), n); err != nil {
+			return nil
+		}
+		return n
+	case models.NotifierChannelWebhook:
+		n := &webhookNotifier{}
+		if err := json.Unmarshal([]byte(cfg.Config), n); err != nil {
+			return nil
+		}
+		return n
+	default:
+		return nil
+	}
+}
+
+// wechatWorkNotifier 企业微信群机器人
+type wechatWorkNotifier struct {
+	WebhookURL string `json:"webhookUrl"`
+}
+
+func (n *wechatWorkNotifier) Channel() string { return models.NotifierChannelWeChatWork }
+
+func (n *wechatWorkNotifier) Send(ctx context.Context, payload AlertPayload) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"msgtype": "text",
+		"text": map[string]string{
+			"content": alertText(payload),
+		},
+	})
+	return postJSON(ctx, n.WebhookURL, body)
+}
+
+// wechatOANotifier 微信公众号模板消息
+type wechatOANotifier struct {
+	AccessToken string `json:"accessToken"`
+	TemplateID  string `json:"templateId"`
+}
+
+func (n *wechatOANotifier) Channel() string { return models.NotifierChannelWeChatOA }
+
+func (n *wechatOANotifier) Send(ctx context.Context, payload AlertPayload) error {
+	if payload.WeChatOpenID == "" {
+		return fmt.Errorf("notifier: wechat_oa requires the subscriber's openid")
+	}
+	url := fmt.Sprintf("https://api.weixin.qq.com/cgi-bin/message/template/send?access_token=%s", n.AccessToken)
+	body, _ := json.Marshal(map[string]interface{}{
+		"touser":      payload.WeChatOpenID,
+		"template_id": n.TemplateID,
+		"data": map[string]interface{}{
+			"first": map[string]string{"value": "电费余额过低提醒"},
+			"keyword1": map[string]string{"value": fmt.Sprintf("%.2f 元", payload.Balance)},
+			"keyword2": map[string]string{"value": payload.Campus},
+		},
+	})
+	return postJSON(ctx, url, body)
+}
+
+// emailNotifier SMTP 邮件，凭据（服务器 / 账号 / 密码）来自管理员配置的共享渠道，
+// 收件地址来自订阅本身，每个用户各收各的
+type emailNotifier struct {
+	SMTPAddr string `json:"smtpAddr"`
+	From     string `json:"from"`
+	Password string `json:"password"`
+}
+
+func (n *emailNotifier) Channel() string { return models.NotifierChannelEmail }
+
+func (n *emailNotifier) Send(ctx context.Context, payload AlertPayload) error {
+	if payload.Email == "" {
+		return fmt.Errorf("notifier: email requires the subscriber's email address")
+	}
+	host := n.SMTPAddr
+	if idx := bytes.IndexByte([]byte(host), ':'); idx >= 0 {
+		host = host[:idx]
+	}
+	auth := smtp.PlainAuth("", n.From, n.Password, host)
+	msg := []byte("Subject: 电费余额过低提醒\r\n\r\n" + alertText(payload))
+	return smtp.SendMail(n.SMTPAddr, auth, n.From, []string{payload.Email}, msg)
+}
+
+// webhookNotifier 通用 JSON Webhook，目标 URL 来自订阅本身而不是渠道配置，
+// 这样不同用户可以各自接到自己的下游系统
+type webhookNotifier struct{}
+
+func (n *webhookNotifier) Channel() string { return models.NotifierChannelWebhook }
+
+func (n *webhookNotifier) Send(ctx context.Context, payload AlertPayload) error {
+	if payload.WebhookURL == "" {
+		return fmt.Errorf("notifier: webhook requires the subscriber's webhook url")
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"userId":    payload.UserID,
+		"campus":    payload.Campus,
+		"balance":   payload.Balance,
+		"threshold": payload.Threshold,
+		"time":      time.Now().Format(time.RFC3339),
+	})
+	return postJSON(ctx, payload.WebhookURL, body)
+}
+
+func alertText(payload AlertPayload) string {
+	return fmt.Sprintf("【电费提醒】您在 %s 校区的电费余额仅剩 %.2f 元，已低于阈值 %d 元，请及时充值。",
+		payload.Campus, payload.Balance, payload.Threshold)
+}
+
+func postJSON(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notifier: unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package alertServices
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// ValidateWebhookURL 校验用户提交的 webhook 地址：只允许 http/https，且不允许指向内网/
+// 回环/链路本地地址，避免服务端被当成 SSRF 跳板反复请求内网服务（元数据接口、内部管理
+// 接口、Redis 等）。worker 轮询期间只要余额持续低于阈值就会反复投递，所以这里要在订阅
+// 保存前就拒绝，而不是等到投递失败才发现
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("notifier: invalid webhook url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("notifier: webhook url must be http or https")
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("notifier: webhook url must have a host")
+	}
+
+	ips, err := resolveHost(host)
+	if err != nil {
+		return fmt.Errorf("notifier: cannot resolve webhook host: %w", err)
+	}
+	for _, ip := range ips {
+		if isDisallowedTarget(ip) {
+			return fmt.Errorf("notifier: webhook url must not point at a private/loopback/link-local address")
+		}
+	}
+	return nil
+}
+
+func resolveHost(host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	return net.LookupIP(host)
+}
+
+// isDisallowedTarget 排除内网、回环、链路本地（含 169.254.169.254 这类云元数据地址）、
+// 以及未指定地址
+func isDisallowedTarget(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified()
+}
@@ -0,0 +1,31 @@
+package alertServices
+
+import "testing"
+
+func TestValidateWebhookURLRejectsInternalTargets(t *testing.T) {
+	cases := []struct {
+		name string
+		url  string
+	}{
+		{"loopback", "http://127.0.0.1:6379/"},
+		{"loopback host", "http://localhost/admin"},
+		{"private 10/8", "http://10.0.0.5/"},
+		{"private 192.168/16", "http://192.168.1.1/"},
+		{"link-local metadata", "http://169.254.169.254/latest/meta-data/"},
+		{"non-http scheme", "file:///etc/passwd"},
+		{"no host", "http:///path"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := ValidateWebhookURL(c.url); err == nil {
+				t.Fatalf("ValidateWebhookURL(%q) = nil, want an error", c.url)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookURLAllowsPublicTarget(t *testing.T) {
+	if err := ValidateWebhookURL("https://203.0.113.10/hooks/alert"); err != nil {
+		t.Fatalf("ValidateWebhookURL(public IP) = %v, want nil", err)
+	}
+}
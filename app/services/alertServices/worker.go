@@ -0,0 +1,160 @@
+package alertServices
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"wejh-go/app/models"
+	"wejh-go/app/services/yxyServices"
+	"wejh-go/config"
+)
+
+const (
+	// pollInterval 轮询所有订阅的间隔
+	pollInterval = 5 * time.Minute
+	// cooldown 同一订阅两次提醒之间的最短间隔，避免余额在阈值附近反复触发
+	cooldown = 6 * time.Hour
+)
+
+// StartWorker 启动低电量提醒后台轮询，随进程生命周期运行，ctx 取消时退出
+func StartWorker(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		pollOnce()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollOnce 扫描一轮所有有效订阅并按需投递提醒
+func pollOnce() {
+	var subs []models.LowBatteryAlertSubscription
+	if err := config.DB.Where("active = ?", true).Find(&subs).Error; err != nil {
+		log.Printf("alertServices: list subscriptions failed: %v", err)
+		return
+	}
+
+	notifiers := loadNotifiers()
+	if len(notifiers) == 0 {
+		return
+	}
+
+	for _, sub := range subs {
+		checkAndDispatch(sub, notifiers)
+	}
+}
+
+// loadNotifiers 读取并构造当前所有启用的渠道
+func loadNotifiers() map[string]Notifier {
+	var cfgs []models.NotifierChannelConfig
+	if err := config.DB.Where("enabled = ?", true).Find(&cfgs).Error; err != nil {
+		log.Printf("alertServices: list channel configs failed: %v", err)
+		return nil
+	}
+
+	out := make(map[string]Notifier, len(cfgs))
+	for _, cfg := range cfgs {
+		if n := newNotifier(cfg); n != nil {
+			out[n.Channel()] = n
+		}
+	}
+	return out
+}
+
+// checkAndDispatch 检查单个订阅是否需要提醒：余额低于阈值，且未处于冷却期，或余额较上次提醒进一步下降
+func checkAndDispatch(sub models.LowBatteryAlertSubscription, notifiers map[string]Notifier) {
+	balance, err := yxyServices.ElectricityBalance(sub.YxyUid, sub.Campus)
+	if err != nil {
+		return
+	}
+	if float64(sub.Threshold) <= balance {
+		return
+	}
+
+	var lastLog models.NotificationLog
+	err = config.DB.Where("subscription_id = ? AND success = ?", sub.ID, true).
+		Order("sent_at DESC").First(&lastLog).Error
+	if err == nil {
+		withinCooldown := time.Since(lastLog.SentAt) < cooldown
+		balanceDropped := balance < lastLog.Balance
+		if withinCooldown && !balanceDropped {
+			return
+		}
+	}
+
+	dispatch(sub, balance, notifiers)
+	// 告警已经确认余额过低，主动清掉缓存，避免用户告警后立刻打开 App 却看到轮询之前的旧余额
+	yxyServices.InvalidateBalanceCache(context.Background(), sub.YxyUid, sub.Campus)
+}
+
+// dispatch 向订阅选择的渠道逐一投递，并写入 notification_log 审计
+func dispatch(sub models.LowBatteryAlertSubscription, balance float64, notifiers map[string]Notifier) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	payload := AlertPayload{
+		UserID:       sub.UserID,
+		Campus:       sub.Campus,
+		Balance:      balance,
+		Threshold:    sub.Threshold,
+		Email:        sub.Email,
+		WebhookURL:   sub.WebhookURL,
+		WeChatOpenID: sub.WeChatOpenID,
+	}
+
+	for _, channel := range channelsOf(sub) {
+		notifier, ok := notifiers[channel]
+		if !ok {
+			continue
+		}
+		sendErr := notifier.Send(ctx, payload)
+		entry := models.NotificationLog{
+			SubscriptionID: sub.ID,
+			UserID:         sub.UserID,
+			Channel:        channel,
+			Balance:        balance,
+			Threshold:      sub.Threshold,
+			Success:        sendErr == nil,
+			SentAt:         time.Now(),
+		}
+		if sendErr != nil {
+			entry.Error = sendErr.Error()
+			log.Printf("alertServices: dispatch via %s for user %d failed: %v", channel, sub.UserID, sendErr)
+		}
+		if err := config.DB.Create(&entry).Error; err != nil {
+			log.Printf("alertServices: write notification_log failed: %v", err)
+		}
+	}
+}
+
+// channelsOf 解析订阅中用户选择的渠道列表
+func channelsOf(sub models.LowBatteryAlertSubscription) []string {
+	if sub.Channels == "" {
+		return []string{models.NotifierChannelWeChatOA}
+	}
+	parts := strings.Split(sub.Channels, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// DispatchNow 立即对单个订阅执行一次检查并投递，供管理端手动触发使用
+func DispatchNow(subID uint) error {
+	var sub models.LowBatteryAlertSubscription
+	if err := config.DB.First(&sub, subID).Error; err != nil {
+		return err
+	}
+	checkAndDispatch(sub, loadNotifiers())
+	return nil
+}
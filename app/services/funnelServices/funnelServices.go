@@ -2,15 +2,21 @@ package funnelServices
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"log"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"wejh-go/app/apiException"
 	"wejh-go/app/utils/circuitBreaker"
 	"wejh-go/app/utils/fetch"
+	"wejh-go/app/utils/metrics"
 	"wejh-go/config/api/funnelApi"
 )
 
@@ -25,6 +31,9 @@ const (
 	funnelCodeOAuthNotUpdate = 416 // 统一密码未更新
 )
 
+// hedgeStagger 对冲请求按 List 打分顺序依次发起的间隔，避免一开始就打满所有节点
+const hedgeStagger = 30 * time.Millisecond
+
 // FunnelResponse 后端统一响应格式
 type FunnelResponse struct {
 	Code int         `json:"code" binding:"required"`
@@ -32,10 +41,31 @@ type FunnelResponse struct {
 	Data interface{} `json:"data"`
 }
 
+type requestIDKeyType struct{}
+
+// requestIDKey 是 ctx 中携带请求 ID 的 key，用于串联同一次调用在 funnel 侧的日志
+var requestIDKey requestIDKeyType
+
+// newRequestID 生成一个短的十六进制请求 ID
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
+
 // 对单个后端节点做一次带 413 重试的调用
-func singleHostRequest(ctx context.Context, host string, api funnelApi.FunnelApi, form url.Values) (FunnelResponse, error) {
+// loginType 用于关联该节点在 circuitBreaker 中的滚动统计（ewmaLatency / inflight / successRate）
+func singleHostRequest(ctx context.Context, host string, api funnelApi.FunnelApi, form url.Values, loginType funnelApi.LoginType) (FunnelResponse, error) {
 	f := fetch.Fetch{}
 	f.Init()
+	if reqID, ok := ctx.Value(requestIDKey).(string); ok {
+		if f.Headers == nil {
+			f.Headers = map[string]string{}
+		}
+		f.Headers["X-Request-Id"] = reqID
+	}
 
 	var rc FunnelResponse
 
@@ -46,13 +76,24 @@ func singleHostRequest(ctx context.Context, host string, api funnelApi.FunnelApi
 	default:
 	}
 
+	finish := circuitBreaker.CB.LB.Begin(host, loginType)
+	start := time.Now()
 	res, err := f.PostForm(host+string(api), form)
 	if err != nil {
+		finish(false)
+		metrics.RequestDuration.WithLabelValues(host, string(loginType)).Observe(time.Since(start).Seconds())
+		metrics.RequestsTotal.WithLabelValues(host, string(loginType), "network_error").Inc()
 		return FunnelResponse{}, apiException.RequestError
 	}
 	if err = json.Unmarshal(res, &rc); err != nil {
+		finish(false)
+		metrics.RequestDuration.WithLabelValues(host, string(loginType)).Observe(time.Since(start).Seconds())
+		metrics.RequestsTotal.WithLabelValues(host, string(loginType), "unmarshal_error").Inc()
 		return FunnelResponse{}, apiException.RequestError
 	}
+	finish(rc.Code == funnelCodeSuccess)
+	metrics.RequestDuration.WithLabelValues(host, string(loginType)).Observe(time.Since(start).Seconds())
+	metrics.RequestsTotal.WithLabelValues(host, string(loginType), strconv.Itoa(rc.Code)).Inc()
 
 	return rc, nil
 }
@@ -64,11 +105,13 @@ func FetchHandleOfPost(form url.Values, host string, api funnelApi.FunnelApi) (i
 	loginType := funnelApi.LoginType(form.Get("type"))
 	// 「是否 ZF 接口」用原来的约定：URL 中包含 "zf"
 	zfFlag := strings.Contains(string(api), "zf")
+	reqID := newRequestID()
 
 	// 非 ZF 接口：保持原来的串行逻辑
 	if !zfFlag {
 		// 非对冲场景用 Background 的 ctx，行为与旧实现一致
-		rc, err := singleHostRequest(context.Background(), host, api, form)
+		ctx := context.WithValue(context.Background(), requestIDKey, reqID)
+		rc, err := singleHostRequest(ctx, host, api, form, loginType)
 		if err != nil {
 			// 对调用异常统一视为 ServerError
 			return nil, apiException.ServerError
@@ -93,8 +136,8 @@ func FetchHandleOfPost(form url.Values, host string, api funnelApi.FunnelApi) (i
 		}
 	}
 
-	// 拿出当前健康的节点集合
-	hosts := circuitBreaker.CB.LB.List(loginType)
+	// 拿出当前允许派发请求的节点集合（已按分数排序，Open 状态节点已被熔断器排除）
+	hosts := circuitBreaker.CB.List(loginType)
 
 	// 调用方通过 GetApi 传进来的 host 优先级最高，把它挪到列表最前面
 	if host != "" {
@@ -127,16 +170,19 @@ func FetchHandleOfPost(form url.Values, host string, api funnelApi.FunnelApi) (i
 	}
 
 	// 对冲用的 ctx：一旦某个节点拿到最终结果，cancel() 终止其它 goroutine 的后续工作
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), requestIDKey, reqID))
 	defer cancel()
 
+	hedgeStart := time.Now()
+
 	resultCh := make(chan result, len(hosts))
 	var wg sync.WaitGroup
 
-	// 并发对冲
-	for _, h := range hosts {
+	// 并发对冲：hosts 已按 ewmaLatency*(inflight+1)/successRate 从优到劣排序，
+	// 第一个节点立即发起，其余按 hedgeStagger 间隔依次跟上，避免无谓地打满弱节点
+	for i, h := range hosts {
 		wg.Add(1)
-		go func(h string) {
+		go func(h string, delay time.Duration) {
 			defer wg.Done()
 			// 独立 goroutine 需要自己的 recover，避免撞穿 gin 的 Recovery
 			defer func() {
@@ -146,6 +192,14 @@ func FetchHandleOfPost(form url.Values, host string, api funnelApi.FunnelApi) (i
 				}
 			}()
 
+			if delay > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(delay):
+				}
+			}
+
 			// 如果已经有其他节点成功了，可以尽量避免无意义请求
 			select {
 			case <-ctx.Done():
@@ -153,7 +207,7 @@ func FetchHandleOfPost(form url.Values, host string, api funnelApi.FunnelApi) (i
 			default:
 			}
 
-			rc, err := singleHostRequest(ctx, h, api, form)
+			rc, err := singleHostRequest(ctx, h, api, form, loginType)
 
 			// 如果上层已经 cancel，不再阻塞在写 channel 上
 			select {
@@ -161,7 +215,7 @@ func FetchHandleOfPost(form url.Values, host string, api funnelApi.FunnelApi) (i
 			case <-ctx.Done():
 				// 上层已经有结果了，丢弃即可
 			}
-		}(h)
+		}(h, time.Duration(i)*hedgeStagger)
 	}
 
 	// 等所有协程结束后关闭通道
@@ -197,18 +251,21 @@ func FetchHandleOfPost(form url.Values, host string, api funnelApi.FunnelApi) (i
 			// 节点健康
 			circuitBreaker.CB.Success(r.host, loginType)
 			cancel()
+			logHedgeWinner(reqID, hosts, r.host, loginType, hedgeStart)
 			return r.rc.Data, nil
 
 		case funnelCodeWrongPassword:
 			// 密码错误：业务错误，节点本身是健康的
 			circuitBreaker.CB.Success(r.host, loginType)
 			cancel()
+			logHedgeWinner(reqID, hosts, r.host, loginType, hedgeStart)
 			return nil, apiException.NoThatPasswordOrWrong
 
 		case funnelCodeOAuthNotUpdate:
 			// 统一密码未更新：业务错误，节点健康
 			circuitBreaker.CB.Success(r.host, loginType)
 			cancel()
+			logHedgeWinner(reqID, hosts, r.host, loginType, hedgeStart)
 			return nil, apiException.OAuthNotUpdate
 
 		// 410 / 413 / 414 / 415 以及其它未知 code
@@ -224,5 +281,15 @@ func FetchHandleOfPost(form url.Values, host string, api funnelApi.FunnelApi) (i
 	if firstErr == nil {
 		firstErr = apiException.ServerError
 	}
+	// 全军覆没：没有任何节点给出可用响应，记一条摘要方便定位 NoApiAvailable / ServerError 的成因
+	log.Printf("funnel hedge reqId=%s: %d hosts raced, no winner, elapsed=%s",
+		reqID, len(hosts), time.Since(hedgeStart))
 	return nil, firstErr
 }
+
+// logHedgeWinner 打印一次对冲的结果摘要：参与节点数、胜出节点、耗时，其余节点视为已取消
+func logHedgeWinner(reqID string, hosts []string, winner string, loginType funnelApi.LoginType, start time.Time) {
+	metrics.HedgeWins.WithLabelValues(winner, string(loginType)).Inc()
+	log.Printf("funnel hedge reqId=%s: %d hosts raced, winner=%s, latency=%s, losers cancelled",
+		reqID, len(hosts), winner, time.Since(start))
+}
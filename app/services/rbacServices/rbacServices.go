@@ -0,0 +1,155 @@
+// Package rbacServices 基于 casbin 的角色-权限管理，策略存放在 DB 的 casbin_rule 表中，
+// 支持运行时 Reload，不需要重启进程即可生效
+package rbacServices
+
+import (
+	"strconv"
+	"sync"
+
+	"wejh-go/config"
+
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+)
+
+// 内置角色，从低到高
+const (
+	RoleStudent    = "student"
+	RoleStaff      = "staff"
+	RoleAdmin      = "admin"
+	RoleSuperadmin = "superadmin"
+)
+
+// rbacModelText 是一个标准的 RBAC + 路径匹配模型：g 表管理「用户 -> 角色」，p 表管理「角色 -> 路径 -> 方法」
+const rbacModelText = `
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub) && keyMatch2(r.obj, p.obj) && r.act == p.act
+`
+
+var (
+	enforcer   *casbin.Enforcer
+	enforcerMu sync.RWMutex
+)
+
+// Init 用 config.DB 作为策略存储初始化 enforcer，需要在服务启动阶段调用一次
+func Init() error {
+	adapter, err := gormadapter.NewAdapterByDB(config.DB)
+	if err != nil {
+		return err
+	}
+
+	m, err := model.NewModelFromString(rbacModelText)
+	if err != nil {
+		return err
+	}
+
+	e, err := casbin.NewEnforcer(m, adapter)
+	if err != nil {
+		return err
+	}
+
+	enforcerMu.Lock()
+	enforcer = e
+	enforcerMu.Unlock()
+	return nil
+}
+
+// Enforcer 返回当前的 enforcer，供中间件调用 Enforce
+func Enforcer() *casbin.Enforcer {
+	enforcerMu.RLock()
+	defer enforcerMu.RUnlock()
+	return enforcer
+}
+
+// Reload 从 DB 重新加载策略，供 /admin/rbac/reload 之类的接口在改完策略后调用
+func Reload() error {
+	e := Enforcer()
+	if e == nil {
+		return nil
+	}
+	return e.LoadPolicy()
+}
+
+// AssignRole 把用户加入某个角色分组（g 策略）
+func AssignRole(userID uint, role string) error {
+	_, err := Enforcer().AddGroupingPolicy(strconv.FormatUint(uint64(userID), 10), role)
+	return err
+}
+
+// EnsureSuperadmin 保证 userID 拥有 superadmin 角色，用于部署时引导出第一个能调用
+// /admin/rbac/assign 的账号——否则 /admin/* 全部挂了 RBAC 之后，没有人能在不手工改 DB
+// 的情况下把自己加进 superadmin 分组。userID 为 0（未配置）时什么都不做；已经拥有该角色
+// 不会重复写入
+func EnsureSuperadmin(userID uint) error {
+	if userID == 0 {
+		return nil
+	}
+	e := Enforcer()
+	if e == nil {
+		return nil
+	}
+
+	sub := strconv.FormatUint(uint64(userID), 10)
+	has, err := e.HasGroupingPolicy(sub, RoleSuperadmin)
+	if err != nil {
+		return err
+	}
+	if has {
+		return nil
+	}
+	if _, err := e.AddGroupingPolicy(sub, RoleSuperadmin); err != nil {
+		return err
+	}
+	return e.SavePolicy()
+}
+
+// SeedDefaultPolicies 为已有路由写入默认策略：student / staff 可以访问电费相关的查询与订阅接口，
+// admin / superadmin 可以访问全部 /admin/* 接口。RBAC 这一层只管路径粒度的准入，
+// “只能看到自己的电费数据”是各 handler 按 session 里的 user 过滤出来的，不是这里的策略能表达的。
+// 已存在的策略不会重复写入
+func SeedDefaultPolicies() error {
+	e := Enforcer()
+	if e == nil {
+		return nil
+	}
+
+	defaults := [][]string{
+		{RoleStudent, "/api/electricity/*", "GET"},
+		{RoleStudent, "/api/electricity/*", "POST"},
+		{RoleStaff, "/api/electricity/*", "GET"},
+		{RoleStaff, "/api/electricity/*", "POST"},
+		{RoleAdmin, "/admin/*", "GET"},
+		{RoleAdmin, "/admin/*", "POST"},
+		{RoleAdmin, "/admin/*", "DELETE"},
+		{RoleSuperadmin, "/admin/*", "GET"},
+		{RoleSuperadmin, "/admin/*", "POST"},
+		{RoleSuperadmin, "/admin/*", "DELETE"},
+	}
+
+	for _, p := range defaults {
+		if _, err := e.AddPolicy(p[0], p[1], p[2]); err != nil {
+			return err
+		}
+	}
+	// admin 继承 staff 的全部权限，superadmin 继承 admin
+	if _, err := e.AddGroupingPolicy(RoleAdmin, RoleStaff); err != nil {
+		return err
+	}
+	if _, err := e.AddGroupingPolicy(RoleSuperadmin, RoleAdmin); err != nil {
+		return err
+	}
+	return e.SavePolicy()
+}
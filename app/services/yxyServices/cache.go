@@ -0,0 +1,128 @@
+package yxyServices
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"wejh-go/app/utils/metrics"
+	"wejh-go/config"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// 各类查询的缓存时长：余额变化快，房间字符串几乎不变，流水记录居中
+const (
+	balanceCacheTTL = 60 * time.Second
+	roomStrCacheTTL = 24 * time.Hour
+	recordsCacheTTL = 5 * time.Minute
+)
+
+// sfGroup 把同一时刻对同一 key 的并发回源请求合并成一次，避免缓存击穿
+var sfGroup singleflight.Group
+
+func cacheKey(parts ...string) string {
+	key := "yxy"
+	for _, p := range parts {
+		key += ":" + p
+	}
+	return key
+}
+
+// cacheAside 是通用的「Redis 旁路缓存 + singleflight 去重」封装，按类型参数化，这样缓存命中
+// 和缓存未命中两条路径返回的是同一个 Go 类型，序列化结果不会因为走没走缓存而不一样：
+// - refresh=true 或缓存未命中时，用 singleflight 合并并发回源，只有一个 goroutine 真正调用 load，
+//   其余等待方共享同一个结果
+// - 回源结果会写回 Redis 并设置 ttl；load 返回的 error 原样透传，方便上层用 errors.Is 判断具体异常
+// - 命中时用 UseNumber 解码，避免调用方用 interface{} 接收时数字被精度有损地转成 float64
+func cacheAside[T any](ctx context.Context, key, resource string, ttl time.Duration, refresh bool, load func() (T, error)) (T, error) {
+	var zero T
+
+	if !refresh {
+		if cached, err := config.Redis.Get(ctx, key).Result(); err == nil {
+			var v T
+			dec := json.NewDecoder(strings.NewReader(cached))
+			dec.UseNumber()
+			if jsonErr := dec.Decode(&v); jsonErr == nil {
+				metrics.CacheHits.WithLabelValues(resource).Inc()
+				return v, nil
+			}
+		}
+	}
+
+	metrics.CacheMisses.WithLabelValues(resource).Inc()
+
+	v, err, _ := sfGroup.Do(key, func() (interface{}, error) {
+		return load()
+	})
+	if err != nil {
+		return zero, err
+	}
+	typed := v.(T)
+
+	if data, jsonErr := json.Marshal(typed); jsonErr == nil {
+		config.Redis.Set(ctx, key, data, ttl)
+	}
+	return typed, nil
+}
+
+// invalidate 删除某个 key 对应的缓存，用于「拿到最新结果后主动使旧缓存失效」的场景
+func invalidate(ctx context.Context, key string) {
+	config.Redis.Del(ctx, key)
+}
+
+// GetBalanceCached 是 ElectricityBalance 的缓存版本，refresh=true 时强制回源
+func GetBalanceCached(ctx context.Context, yxyUid, campus string, refresh bool) (float64, error) {
+	key := cacheKey("balance", yxyUid, campus)
+	return cacheAside(ctx, key, "balance", balanceCacheTTL, refresh, func() (float64, error) {
+		return ElectricityBalance(yxyUid, campus)
+	})
+}
+
+// InvalidateBalanceCache 使某个用户的余额缓存立即失效，低电量轮询在告警后调用，
+// 保证用户紧接着打开 App 看到的也是最新余额，而不是告警判断之前的旧缓存
+func InvalidateBalanceCache(ctx context.Context, yxyUid, campus string) {
+	invalidate(ctx, cacheKey("balance", yxyUid, campus))
+}
+
+// GetRoomStrConcatCached 是 GetElecRoomStrConcat 的缓存版本，24 小时内基本不会变化
+func GetRoomStrConcatCached(ctx context.Context, yxyUid, campus string, refresh bool) (*string, error) {
+	key := cacheKey("roomstr", yxyUid, campus)
+	s, err := cacheAside(ctx, key, "roomStr", roomStrCacheTTL, refresh, func() (string, error) {
+		s, err := GetElecRoomStrConcat(yxyUid, campus)
+		if err != nil {
+			return "", err
+		}
+		return *s, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetRechargeRecordsCached 是 ElectricityRechargeRecords 的缓存版本，直接返回 .List，
+// 调用方不需要关心底层记录的具体结构
+func GetRechargeRecordsCached(ctx context.Context, yxyUid, campus, page, roomStrConcat string, refresh bool) (interface{}, error) {
+	key := cacheKey("recharge", yxyUid, campus, page)
+	return cacheAside(ctx, key, "recharge", recordsCacheTTL, refresh, func() (interface{}, error) {
+		records, err := ElectricityRechargeRecords(yxyUid, campus, page, roomStrConcat)
+		if err != nil {
+			return nil, err
+		}
+		return records.List, nil
+	})
+}
+
+// GetConsumptionRecordsCached 是 GetElecConsumptionRecords 的缓存版本，直接返回 .List
+func GetConsumptionRecordsCached(ctx context.Context, yxyUid, campus, roomStrConcat string, refresh bool) (interface{}, error) {
+	key := cacheKey("consumption", yxyUid, campus)
+	return cacheAside(ctx, key, "consumption", recordsCacheTTL, refresh, func() (interface{}, error) {
+		records, err := GetElecConsumptionRecords(yxyUid, campus, roomStrConcat)
+		if err != nil {
+			return nil, err
+		}
+		return records.List, nil
+	})
+}
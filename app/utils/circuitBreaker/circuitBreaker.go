@@ -0,0 +1,322 @@
+package circuitBreaker
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"wejh-go/app/models"
+	"wejh-go/config"
+	"wejh-go/config/api/funnelApi"
+)
+
+// State 熔断器三态
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// breakerConfig 单个策略的运行参数，来自 models.CircuitBreakerConfig
+type breakerConfig struct {
+	failureThreshold float64
+	minSamples       int
+	window           time.Duration
+	cooldown         time.Duration
+}
+
+// defaultBreakerConfig 在 DB 中没有任何配置时使用的兜底策略
+var defaultBreakerConfig = breakerConfig{
+	failureThreshold: 0.5,
+	minSamples:       10,
+	window:           60 * time.Second,
+	cooldown:         5 * time.Second,
+}
+
+// probeTimeout 探测请求放出后，如果迟迟没有通过 record() 报告结果（比如对冲的其它节点
+// 更快返回，ctx 被 cancel，探测请求的 goroutine 在真正发起调用前就直接退出了），
+// 探测名额会在这个时限后自动释放，避免一次性的取消把节点永久锁在 HalfOpen 里
+const probeTimeout = 10 * time.Second
+
+type callRecord struct {
+	at      time.Time
+	success bool
+}
+
+// breaker 维护单个 (host, loginType) 的滑动窗口调用记录和当前状态。
+// cfg 不在创建时快照，而是每次从 cb 持有的配置里现读（见 cfg 方法），这样
+// ReloadConfig 才能对已经有流量、已经创建过 breaker 的节点立即生效
+type breaker struct {
+	mu sync.Mutex
+
+	cb  *CircuitBreaker
+	key string
+
+	state    State
+	window   []callRecord
+	opens    int       // 连续 Open 的次数，用于指数退避冷却时长
+	openAt   time.Time // 进入当前 Open 状态的时间
+	probing  bool      // HalfOpen 下是否已经放出过一个探测请求
+	probedAt time.Time // 放出当前探测请求的时间，用于 probeTimeout 判断是否需要释放名额
+}
+
+// cfg 实时读取当前生效的配置，而不是使用创建时的快照，使 ReloadConfig 对已存在的
+// breaker 也能生效
+func (b *breaker) cfg() breakerConfig {
+	return b.cb.configFor(b.key)
+}
+
+// record 记录一次调用结果，并在必要时驱动状态迁移
+func (b *breaker) record(host string, loginType funnelApi.LoginType, success bool) {
+	b.mu.Lock()
+	now := time.Now()
+	b.window = append(b.window, callRecord{at: now, success: success})
+	b.trimWindow(now)
+
+	switch b.state {
+	case HalfOpen:
+		b.probing = false
+		if success {
+			b.transition(host, loginType, Closed)
+			b.opens = 0
+			b.window = nil
+		} else {
+			b.opens++
+			b.transition(host, loginType, Open)
+			b.openAt = now
+		}
+	case Closed:
+		if b.shouldOpen() {
+			b.opens++
+			b.transition(host, loginType, Open)
+			b.openAt = now
+		}
+	case Open:
+		// Open 期间 List 已经把节点排除在外，这里的记录大概率来自探测前的残留请求，忽略
+	}
+	b.mu.Unlock()
+}
+
+// trimWindow 丢弃滑动窗口外的旧记录
+func (b *breaker) trimWindow(now time.Time) {
+	cutoff := now.Add(-b.cfg().window)
+	i := 0
+	for ; i < len(b.window); i++ {
+		if b.window[i].at.After(cutoff) {
+			break
+		}
+	}
+	b.window = b.window[i:]
+}
+
+// shouldOpen 样本数达到最小要求且失败率超过阈值
+func (b *breaker) shouldOpen() bool {
+	cfg := b.cfg()
+	if len(b.window) < cfg.minSamples {
+		return false
+	}
+	fails := 0
+	for _, r := range b.window {
+		if !r.success {
+			fails++
+		}
+	}
+	return float64(fails)/float64(len(b.window)) > cfg.failureThreshold
+}
+
+// cooldownExpired 判断 Open 状态是否已经冷却到可以进入 HalfOpen，冷却时长随连续 Open 次数指数退避
+func (b *breaker) cooldownExpired(now time.Time) bool {
+	backoff := b.cfg().cooldown
+	for i := 1; i < b.opens; i++ {
+		backoff *= 2
+	}
+	return now.Sub(b.openAt) >= backoff
+}
+
+// allow 由 List 调用，决定该节点当前是否可以被派发请求：
+// - Closed：总是允许
+// - Open：冷却未到期则拒绝；到期则迁移到 HalfOpen 并放出这一次探测
+// - HalfOpen：只允许一个探测在途，其它请求继续拒绝；如果放出的探测超过 probeTimeout
+//   仍没有通过 record() 报告结果（大概率是对冲时 ctx 被提前 cancel，探测请求根本没真正发出去），
+//   视为该名额作废，允许放出新的一次探测
+func (b *breaker) allow(host string, loginType funnelApi.LoginType) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	switch b.state {
+	case Closed:
+		return true
+	case Open:
+		if b.cooldownExpired(now) {
+			b.transition(host, loginType, HalfOpen)
+			b.probing = true
+			b.probedAt = now
+			return true
+		}
+		return false
+	case HalfOpen:
+		if !b.probing || now.Sub(b.probedAt) > probeTimeout {
+			b.probing = true
+			b.probedAt = now
+			return true
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// transition 切换状态并上报事件，供日志 / Prometheus 消费
+func (b *breaker) transition(host string, loginType funnelApi.LoginType, to State) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	log.Printf("circuitBreaker: host=%s loginType=%s state %s -> %s", host, loginType, from, to)
+	if onStateChange != nil {
+		onStateChange(host, loginType, from, to)
+	}
+}
+
+// onStateChange 是一个扩展点，由外部（如 metrics 子系统）注册，在状态迁移时收到通知
+var onStateChange func(host string, loginType funnelApi.LoginType, from, to State)
+
+// OnStateChange 注册状态迁移回调，调用方需要自己保证并发安全（只应在启动阶段调用一次）
+func OnStateChange(f func(host string, loginType funnelApi.LoginType, from, to State)) {
+	onStateChange = f
+}
+
+// CircuitBreaker 把 LoadBalance（打分 / 选点）和按 (host, loginType) 的三态熔断结合起来
+type CircuitBreaker struct {
+	LB *LoadBalance
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+
+	cfgMu      sync.RWMutex
+	defaultCfg breakerConfig
+	hostCfg    map[string]breakerConfig
+}
+
+// CB 是进程内唯一的 CircuitBreaker 实例，由 funnelServices 使用
+var CB = &CircuitBreaker{
+	LB:         &LoadBalance{zfLB: newP2CLB(nil).(*p2cLB), oauthLB: newP2CLB(nil).(*p2cLB)},
+	breakers:   make(map[string]*breaker),
+	defaultCfg: defaultBreakerConfig,
+	hostCfg:    make(map[string]breakerConfig),
+}
+
+func breakerKey(host string, loginType funnelApi.LoginType) string {
+	return host + "|" + string(loginType)
+}
+
+func (cb *CircuitBreaker) breakerFor(host string, loginType funnelApi.LoginType) *breaker {
+	key := breakerKey(host, loginType)
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if b, ok := cb.breakers[key]; ok {
+		return b
+	}
+
+	b := &breaker{cb: cb, key: key, state: Closed}
+	cb.breakers[key] = b
+	return b
+}
+
+// configFor 实时查当前生效的配置：先看有没有该 (host, loginType) 的覆盖配置，没有则用全局默认
+func (cb *CircuitBreaker) configFor(key string) breakerConfig {
+	cb.cfgMu.RLock()
+	defer cb.cfgMu.RUnlock()
+	if cfg, ok := cb.hostCfg[key]; ok {
+		return cfg
+	}
+	return cb.defaultCfg
+}
+
+// Fail 记录一次失败调用
+func (cb *CircuitBreaker) Fail(host string, loginType funnelApi.LoginType) {
+	cb.breakerFor(host, loginType).record(host, loginType, false)
+}
+
+// Success 记录一次成功调用
+func (cb *CircuitBreaker) Success(host string, loginType funnelApi.LoginType) {
+	cb.breakerFor(host, loginType).record(host, loginType, true)
+}
+
+// List 返回当前允许被派发请求的节点，已经按 LB 的打分排序，并排除 Open 状态的节点
+// （HalfOpen 节点只会保留一个用于探测，其余仍被排除）
+func (cb *CircuitBreaker) List(loginType funnelApi.LoginType) []string {
+	all := cb.LB.List(loginType)
+	out := make([]string, 0, len(all))
+	for _, h := range all {
+		if cb.breakerFor(h, loginType).allow(h, loginType) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// State 返回单个节点当前的熔断状态，供 /admin/funnel/stats 之类的只读接口使用
+func (cb *CircuitBreaker) State(host string, loginType funnelApi.LoginType) State {
+	b := cb.breakerFor(host, loginType)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// ReloadConfig 从 DB 重新加载熔断策略（全局默认 + 各节点覆盖），供运维调参后热更新，无需重启
+func (cb *CircuitBreaker) ReloadConfig() error {
+	var rows []models.CircuitBreakerConfig
+	if err := config.DB.Find(&rows).Error; err != nil {
+		return err
+	}
+
+	cb.cfgMu.Lock()
+	defer cb.cfgMu.Unlock()
+
+	hostCfg := make(map[string]breakerConfig, len(rows))
+	for _, row := range rows {
+		cfg := toBreakerConfig(row)
+		if row.Host == "" {
+			cb.defaultCfg = cfg
+			continue
+		}
+		hostCfg[breakerKey(row.Host, funnelApi.LoginType(row.LoginType))] = cfg
+	}
+	cb.hostCfg = hostCfg
+	return nil
+}
+
+func toBreakerConfig(row models.CircuitBreakerConfig) breakerConfig {
+	cfg := defaultBreakerConfig
+	if row.FailureThreshold > 0 {
+		cfg.failureThreshold = row.FailureThreshold
+	}
+	if row.MinSamples > 0 {
+		cfg.minSamples = row.MinSamples
+	}
+	if row.WindowSeconds > 0 {
+		cfg.window = time.Duration(row.WindowSeconds) * time.Second
+	}
+	if row.CooldownSeconds > 0 {
+		cfg.cooldown = time.Duration(row.CooldownSeconds) * time.Second
+	}
+	return cfg
+}
@@ -0,0 +1,173 @@
+package circuitBreaker
+
+import (
+	"testing"
+	"time"
+
+	"wejh-go/config/api/funnelApi"
+)
+
+func newTestCB(cfg breakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		breakers:   make(map[string]*breaker),
+		defaultCfg: cfg,
+		hostCfg:    make(map[string]breakerConfig),
+	}
+}
+
+func TestBreakerOpensAfterFailureThreshold(t *testing.T) {
+	cb := newTestCB(breakerConfig{
+		failureThreshold: 0.5,
+		minSamples:       4,
+		window:           time.Minute,
+		cooldown:         time.Second,
+	})
+
+	host, loginType := "h1", funnelApi.ZF
+	for i := 0; i < 3; i++ {
+		cb.Fail(host, loginType)
+	}
+	if got := cb.State(host, loginType); got != Closed {
+		t.Fatalf("state after 3 failures (below minSamples) = %v, want Closed", got)
+	}
+
+	cb.Fail(host, loginType)
+	if got := cb.State(host, loginType); got != Open {
+		t.Fatalf("state after 4/4 failures = %v, want Open", got)
+	}
+}
+
+func TestBreakerHalfOpenRecoversOnSuccess(t *testing.T) {
+	cb := newTestCB(breakerConfig{
+		failureThreshold: 0.5,
+		minSamples:       1,
+		window:           time.Minute,
+		cooldown:         time.Millisecond,
+	})
+
+	host, loginType := "h1", funnelApi.ZF
+	cb.Fail(host, loginType)
+	if got := cb.State(host, loginType); got != Open {
+		t.Fatalf("state after single failure = %v, want Open", got)
+	}
+
+	b := cb.breakerFor(host, loginType)
+	// 冷却时长只有 1ms，把 openAt 往回拨就不用真的 sleep
+	b.mu.Lock()
+	b.openAt = time.Now().Add(-time.Second)
+	b.mu.Unlock()
+
+	if !b.allow(host, loginType) {
+		t.Fatalf("allow() after cooldown expired = false, want true (should probe once)")
+	}
+	if got := cb.State(host, loginType); got != HalfOpen {
+		t.Fatalf("state after cooldown expiry = %v, want HalfOpen", got)
+	}
+
+	cb.Success(host, loginType)
+	if got := cb.State(host, loginType); got != Closed {
+		t.Fatalf("state after successful probe = %v, want Closed", got)
+	}
+}
+
+func TestBreakerHalfOpenReopensOnFailure(t *testing.T) {
+	cb := newTestCB(breakerConfig{
+		failureThreshold: 0.5,
+		minSamples:       1,
+		window:           time.Minute,
+		cooldown:         time.Millisecond,
+	})
+
+	host, loginType := "h1", funnelApi.ZF
+	cb.Fail(host, loginType)
+	b := cb.breakerFor(host, loginType)
+	b.mu.Lock()
+	b.openAt = time.Now().Add(-time.Second)
+	b.mu.Unlock()
+	b.allow(host, loginType) // 迁移到 HalfOpen 并放出一次探测
+
+	cb.Fail(host, loginType)
+	if got := cb.State(host, loginType); got != Open {
+		t.Fatalf("state after failed probe = %v, want Open", got)
+	}
+	if b.opens != 2 {
+		t.Fatalf("opens = %d, want 2 (for exponential cooldown backoff)", b.opens)
+	}
+}
+
+// TestHalfOpenProbeReleasedAfterTimeout 验证探测请求因为对冲时 ctx 被提前 cancel、
+// 从未真正发起调用、从未 record() 结果的情况下，探测名额不会被永久锁死——这正是
+// chunk0-3 review 指出的「一次瞬时故障导致节点永久被踢出轮换」的 bug
+func TestHalfOpenProbeReleasedAfterTimeout(t *testing.T) {
+	cb := newTestCB(breakerConfig{
+		failureThreshold: 0.5,
+		minSamples:       1,
+		window:           time.Minute,
+		cooldown:         time.Millisecond,
+	})
+
+	host, loginType := "h1", funnelApi.ZF
+	cb.Fail(host, loginType)
+	b := cb.breakerFor(host, loginType)
+	b.mu.Lock()
+	b.openAt = time.Now().Add(-time.Second)
+	b.mu.Unlock()
+
+	if !b.allow(host, loginType) {
+		t.Fatalf("allow() after cooldown expired = false, want true (should probe once)")
+	}
+	if got := cb.State(host, loginType); got != HalfOpen {
+		t.Fatalf("state after cooldown expiry = %v, want HalfOpen", got)
+	}
+
+	// 模拟探测请求被取消、从未调用 record()：紧接着再来一次 allow() 应该仍然被拒绝
+	if b.allow(host, loginType) {
+		t.Fatalf("allow() immediately after an in-flight probe = true, want false (only one probe in flight)")
+	}
+
+	// 把 probedAt 往回拨到超过 probeTimeout，模拟探测请求一直没有结果
+	b.mu.Lock()
+	b.probedAt = time.Now().Add(-probeTimeout - time.Second)
+	b.mu.Unlock()
+
+	if !b.allow(host, loginType) {
+		t.Fatalf("allow() after probeTimeout elapsed = false, want true (stale probe should be released)")
+	}
+	if got := cb.State(host, loginType); got != HalfOpen {
+		t.Fatalf("state after releasing stale probe = %v, want still HalfOpen", got)
+	}
+}
+
+// TestReloadConfigAppliesToExistingBreaker 验证已经创建过的 breaker 会实时读取最新配置，
+// 而不是停留在创建时的快照上——这正是 ReloadConfig 曾经失效的 bug
+func TestReloadConfigAppliesToExistingBreaker(t *testing.T) {
+	cb := newTestCB(breakerConfig{
+		failureThreshold: 0.5,
+		minSamples:       100, // 很高的门槛，几次失败不会触发熔断
+		window:           time.Minute,
+		cooldown:         time.Second,
+	})
+
+	host, loginType := "h1", funnelApi.ZF
+	// 先制造一次调用，使 breaker 被创建并缓存
+	cb.Fail(host, loginType)
+	if got := cb.State(host, loginType); got != Closed {
+		t.Fatalf("state before reload = %v, want Closed", got)
+	}
+
+	// 模拟 ReloadConfig 的效果：把该 host 的配置换成一个更敏感的阈值
+	cb.cfgMu.Lock()
+	cb.hostCfg[breakerKey(host, loginType)] = breakerConfig{
+		failureThreshold: 0.5,
+		minSamples:       1,
+		window:           time.Minute,
+		cooldown:         time.Second,
+	}
+	cb.cfgMu.Unlock()
+
+	// 同一个缓存的 breaker 实例，不经过 breakerFor 重新创建，再来一次失败就应该立刻触发熔断
+	cb.Fail(host, loginType)
+	if got := cb.State(host, loginType); got != Open {
+		t.Fatalf("state after reload + 1 failure = %v, want Open (reload should apply without recreating the breaker)", got)
+	}
+}
@@ -2,6 +2,7 @@ package circuitBreaker
 
 import (
 	"sync"
+	"time"
 
 	"wejh-go/app/apiException"
 	"wejh-go/config/api/funnelApi"
@@ -12,16 +13,16 @@ import (
 type LoadBalanceType int
 
 const (
-	Random LoadBalanceType = iota
+	PowerOfTwoChoices LoadBalanceType = iota
 )
 
 // LoadBalance 维护两套池：ZF / OAuth
 type LoadBalance struct {
-	zfLB    *randomLB
-	oauthLB *randomLB
+	zfLB    *p2cLB
+	oauthLB *p2cLB
 }
 
-// Pick 原有随机负载均衡逻辑（为了兼容）
+// Pick 原有接口（为了兼容）：在两套池之间按权重选一个可用池，再从池内挑一个节点
 func (lb *LoadBalance) Pick(zfFlag, oauthFlag bool) (string, funnelApi.LoginType, error) {
 	oauthAvailable := oauthFlag && lb.oauthLB.isAvailable()
 	zfAvailable := zfFlag && lb.zfLB.isAvailable()
@@ -43,7 +44,8 @@ func (lb *LoadBalance) Pick(zfFlag, oauthFlag bool) (string, funnelApi.LoginType
 	return "", funnelApi.Unknown, apiException.NoApiAvailable
 }
 
-// List 返回当前可用后端节点的快照
+// List 返回当前可用后端节点，按 ewmaLatency*(inflight+1)/successRate 从优到劣排序，
+// 供 FetchHandleOfPost 做对冲：先打分最好的节点，再按延迟依次 stagger 其余节点
 // - loginType 为 Oauth：返回 OAuth 池
 // - 其它（ZF / Unknown）：统一返回 ZF 池
 func (lb *LoadBalance) List(loginType funnelApi.LoginType) []string {
@@ -52,15 +54,45 @@ func (lb *LoadBalance) List(loginType funnelApi.LoginType) []string {
 		if lb.oauthLB == nil {
 			return nil
 		}
-		return lb.oauthLB.list()
+		return lb.oauthLB.listByScore()
 	default:
 		if lb.zfLB == nil {
 			return nil
 		}
-		return lb.zfLB.list()
+		return lb.zfLB.listByScore()
 	}
 }
 
+// Begin 在发起一次请求前调用，inflight 计数加一并记录起始时间；
+// 返回的 finish 需要在请求结束时调用一次，用于更新 ewmaLatency / successRate 并把 inflight 计数减一
+func (lb *LoadBalance) Begin(host string, loginType funnelApi.LoginType) (finish func(success bool)) {
+	return lb.poolOf(loginType).begin(host, loginType)
+}
+
+// HostStat 是 hostScore 对外暴露的快照，供 /admin/funnel/stats 展示
+type HostStat struct {
+	Host        string  `json:"host"`
+	EwmaLatency float64 `json:"ewmaLatencyMs"`
+	Inflight    int64   `json:"inflight"`
+	SuccessRate float64 `json:"successRate"`
+	Score       float64 `json:"score"`
+}
+
+// Stats 返回两套池当前的滚动统计快照
+func (lb *LoadBalance) Stats() map[funnelApi.LoginType][]HostStat {
+	return map[funnelApi.LoginType][]HostStat{
+		funnelApi.ZF:    lb.zfLB.stats(),
+		funnelApi.Oauth: lb.oauthLB.stats(),
+	}
+}
+
+func (lb *LoadBalance) poolOf(loginType funnelApi.LoginType) *p2cLB {
+	if loginType == funnelApi.Oauth {
+		return lb.oauthLB
+	}
+	return lb.zfLB
+}
+
 // 在运行时添加节点
 func (lb *LoadBalance) Add(api string, loginType funnelApi.LoginType) {
 	if loginType == funnelApi.Oauth {
@@ -88,61 +120,186 @@ type loadBalance interface {
 	isAvailable() bool
 }
 
-type randomLB struct {
+// hostScore 单个节点的滚动统计，均为 EWMA（指数加权移动平均），用于 P2C 打分
+type hostScore struct {
+	mu          sync.Mutex
+	ewmaLatency float64 // 毫秒
+	inflight    int64
+	successRate float64 // 0~1，初始视为健康
+}
+
+const (
+	latencyDecay    = 0.2 // 新样本在 ewmaLatency 中的权重
+	successRateDecay = 0.1 // 新样本在 successRate 中的权重
+)
+
+// score 值越小越优：延迟 * (在途请求数+1) / 成功率
+func (s *hostScore) score() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	successRate := s.successRate
+	if successRate <= 0 {
+		// 还没有任何样本或近期全部失败，给一个很小的正数避免除零，同时让它在排序中处于劣势
+		successRate = 0.01
+	}
+	return s.ewmaLatency * float64(s.inflight+1) / successRate
+}
+
+func (s *hostScore) onStart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inflight++
+}
+
+func (s *hostScore) onFinish(latency time.Duration, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inflight--
+	if s.inflight < 0 {
+		s.inflight = 0
+	}
+
+	latencyMs := float64(latency.Milliseconds())
+	if s.ewmaLatency == 0 {
+		s.ewmaLatency = latencyMs
+	} else {
+		s.ewmaLatency = s.ewmaLatency*(1-latencyDecay) + latencyMs*latencyDecay
+	}
+
+	sample := 0.0
+	if success {
+		sample = 1.0
+	}
+	if s.successRate == 0 {
+		s.successRate = 1.0
+	}
+	s.successRate = s.successRate*(1-successRateDecay) + sample*successRateDecay
+}
+
+// p2cLB 维护一组后端节点及各自的滚动统计，用 power-of-two-choices 选择节点
+type p2cLB struct {
 	sync.Mutex
-	Api  []string
-	Size int
+	Api    []string
+	Size   int
+	scores map[string]*hostScore
 }
 
-func newRandomLB(apis []string) loadBalance {
-	return &randomLB{Api: apis, Size: len(apis)}
+func newP2CLB(apis []string) loadBalance {
+	b := &p2cLB{Api: apis, Size: len(apis), scores: make(map[string]*hostScore, len(apis))}
+	for _, api := range apis {
+		b.scores[api] = &hostScore{successRate: 1}
+	}
+	return b
 }
 
-func (b *randomLB) LoadBalance() LoadBalanceType {
-	return Random
+func (b *p2cLB) LoadBalance() LoadBalanceType {
+	return PowerOfTwoChoices
 }
 
-// Pick：单次随机选择一个后端
-func (b *randomLB) Pick() string {
+// Pick：从池内全部节点中均匀采样两个，选 score 更小（更优）的一个；
+// 不查看熔断器状态，Open 状态的节点同样可能被选中
+func (b *p2cLB) Pick() string {
 	b.Lock()
-	defer b.Unlock()
+	apis := b.Api
+	b.Unlock()
 
-	if b.Size == 0 {
+	if len(apis) == 0 {
 		return ""
 	}
-	if b.Size == 1 {
-		return b.Api[0]
+	if len(apis) == 1 {
+		return apis[0]
 	}
-	return b.Api[fastrand.Intn(b.Size)]
-}
 
-// list：返回当前后端列表的拷贝，供并发对冲使用
-func (b *randomLB) list() []string {
-	b.Lock()
-	defer b.Unlock()
+	i := fastrand.Intn(len(apis))
+	j := fastrand.Intn(len(apis))
+	for j == i {
+		j = fastrand.Intn(len(apis))
+	}
 
-	if b.Size == 0 {
-		return nil
+	hostI, hostJ := apis[i], apis[j]
+	if b.scoreOf(hostI) <= b.scoreOf(hostJ) {
+		return hostI
 	}
-	out := make([]string, b.Size)
+	return hostJ
+}
+
+// listByScore：返回当前节点列表，按 score 从优到劣排序，供对冲分阶段发起请求
+func (b *p2cLB) listByScore() []string {
+	b.Lock()
+	out := make([]string, len(b.Api))
 	copy(out, b.Api)
+	b.Unlock()
+
+	sortByScore(out, b.scoreOf)
 	return out
 }
 
-func (b *randomLB) ReBalance(apis []string) {
+func (b *p2cLB) scoreOf(host string) float64 {
+	b.Lock()
+	s, ok := b.scores[host]
+	b.Unlock()
+	if !ok {
+		return 0 // 未知节点（刚被 Add），优先尝试一次
+	}
+	return s.score()
+}
+
+func (b *p2cLB) begin(host string, loginType funnelApi.LoginType) (finish func(success bool)) {
+	b.Lock()
+	s, ok := b.scores[host]
+	b.Unlock()
+	if !ok {
+		// 节点已被移除或从未被统计过，finish 为空操作
+		return func(bool) {}
+	}
+
+	s.onStart()
+	if onInflightChange != nil {
+		onInflightChange(host, loginType, 1)
+	}
+	start := time.Now()
+	return func(success bool) {
+		s.onFinish(time.Since(start), success)
+		if onInflightChange != nil {
+			onInflightChange(host, loginType, -1)
+		}
+	}
+}
+
+// onInflightChange 是一个扩展点，由外部（如 metrics 子系统）注册，在 inflight 计数变化时收到通知，
+// delta 为 +1（开始一次请求）或 -1（请求结束）
+var onInflightChange func(host string, loginType funnelApi.LoginType, delta int)
+
+// OnInflightChange 注册 inflight 变化回调，调用方需要自己保证并发安全（只应在启动阶段调用一次）
+func OnInflightChange(f func(host string, loginType funnelApi.LoginType, delta int)) {
+	onInflightChange = f
+}
+
+func (b *p2cLB) ReBalance(apis []string) {
 	b.Lock()
 	defer b.Unlock()
 	b.Api, b.Size = apis, len(apis)
+	b.scores = make(map[string]*hostScore, len(apis))
+	for _, api := range apis {
+		b.scores[api] = &hostScore{successRate: 1}
+	}
 }
 
-func (b *randomLB) Add(api ...string) {
+func (b *p2cLB) Add(api ...string) {
 	b.Lock()
 	defer b.Unlock()
 	b.Api = append(b.Api, api...)
 	b.Size = len(b.Api)
+	for _, a := range api {
+		if _, ok := b.scores[a]; !ok {
+			b.scores[a] = &hostScore{successRate: 1}
+		}
+	}
 }
 
-func (b *randomLB) Remove(api string) {
+func (b *p2cLB) Remove(api string) {
 	b.Lock()
 	defer b.Unlock()
 
@@ -153,10 +310,49 @@ func (b *randomLB) Remove(api string) {
 		}
 	}
 	b.Size = len(b.Api)
+	delete(b.scores, api)
 }
 
-func (b *randomLB) isAvailable() bool {
+// stats 返回该池所有节点的当前统计快照
+func (b *p2cLB) stats() []HostStat {
+	b.Lock()
+	hosts := make([]string, len(b.Api))
+	copy(hosts, b.Api)
+	b.Unlock()
+
+	out := make([]HostStat, 0, len(hosts))
+	for _, h := range hosts {
+		b.Lock()
+		s, ok := b.scores[h]
+		b.Unlock()
+		if !ok {
+			continue
+		}
+		s.mu.Lock()
+		ewmaLatency, inflight, successRate := s.ewmaLatency, s.inflight, s.successRate
+		s.mu.Unlock()
+		out = append(out, HostStat{
+			Host:        h,
+			EwmaLatency: ewmaLatency,
+			Inflight:    inflight,
+			SuccessRate: successRate,
+			Score:       s.score(),
+		})
+	}
+	return out
+}
+
+func (b *p2cLB) isAvailable() bool {
 	b.Lock()
 	defer b.Unlock()
 	return b.Size != 0
 }
+
+// sortByScore 按 score 从小到大排序 hosts，节点数很少（通常个位数），直接插入排序即可
+func sortByScore(hosts []string, score func(string) float64) {
+	for i := 1; i < len(hosts); i++ {
+		for j := i; j > 0 && score(hosts[j-1]) > score(hosts[j]); j-- {
+			hosts[j-1], hosts[j] = hosts[j], hosts[j-1]
+		}
+	}
+}
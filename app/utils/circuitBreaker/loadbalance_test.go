@@ -0,0 +1,106 @@
+package circuitBreaker
+
+import (
+	"testing"
+	"time"
+
+	"wejh-go/config/api/funnelApi"
+)
+
+func TestHostScoreOnStartOnFinish(t *testing.T) {
+	s := &hostScore{successRate: 1}
+
+	s.onStart()
+	if s.inflight != 1 {
+		t.Fatalf("inflight after onStart = %d, want 1", s.inflight)
+	}
+
+	s.onFinish(50*time.Millisecond, true)
+	if s.inflight != 0 {
+		t.Fatalf("inflight after onFinish = %d, want 0", s.inflight)
+	}
+	if s.ewmaLatency != 50 {
+		t.Fatalf("ewmaLatency after first sample = %v, want 50 (first sample seeds the average)", s.ewmaLatency)
+	}
+
+	// 连续失败应该把 successRate 往 0 拉
+	for i := 0; i < 20; i++ {
+		s.onStart()
+		s.onFinish(10*time.Millisecond, false)
+	}
+	if s.successRate > 0.2 {
+		t.Fatalf("successRate after 20 failures = %v, want close to 0", s.successRate)
+	}
+}
+
+func TestHostScoreOnFinishNeverNegative(t *testing.T) {
+	s := &hostScore{successRate: 1}
+	s.onFinish(time.Millisecond, true)
+	if s.inflight != 0 {
+		t.Fatalf("inflight = %d, want clamped to 0 on underflow", s.inflight)
+	}
+}
+
+func TestP2CLBPickPrefersBetterScore(t *testing.T) {
+	b := newP2CLB([]string{"good", "bad"}).(*p2cLB)
+
+	// good：低延迟、满成功率；bad：高延迟、低成功率 —— good 的 score 应该更小
+	b.scores["good"] = &hostScore{ewmaLatency: 10, successRate: 1}
+	b.scores["bad"] = &hostScore{ewmaLatency: 500, successRate: 0.1}
+
+	for i := 0; i < 50; i++ {
+		if got := b.Pick(); got != "good" {
+			t.Fatalf("Pick() = %q, want %q (lower score should always win)", got, "good")
+		}
+	}
+}
+
+func TestP2CLBListByScoreOrdering(t *testing.T) {
+	b := newP2CLB([]string{"a", "b", "c"}).(*p2cLB)
+	b.scores["a"] = &hostScore{ewmaLatency: 300, successRate: 1}
+	b.scores["b"] = &hostScore{ewmaLatency: 10, successRate: 1}
+	b.scores["c"] = &hostScore{ewmaLatency: 100, successRate: 1}
+
+	got := b.listByScore()
+	want := []string{"b", "c", "a"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("listByScore() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestBeginFinishTracksInflightAndFiresCallback(t *testing.T) {
+	orig := onInflightChange
+	defer func() { onInflightChange = orig }()
+
+	var deltas []int
+	OnInflightChange(func(host string, loginType funnelApi.LoginType, delta int) {
+		deltas = append(deltas, delta)
+	})
+
+	b := newP2CLB([]string{"h"}).(*p2cLB)
+	finish := b.begin("h", funnelApi.ZF)
+	if got := b.scores["h"].inflight; got != 1 {
+		t.Fatalf("inflight after begin = %d, want 1", got)
+	}
+
+	finish(true)
+	if got := b.scores["h"].inflight; got != 0 {
+		t.Fatalf("inflight after finish = %d, want 0", got)
+	}
+
+	if len(deltas) != 2 || deltas[0] != 1 || deltas[1] != -1 {
+		t.Fatalf("onInflightChange deltas = %v, want [1 -1]", deltas)
+	}
+}
+
+func TestBeginOnUnknownHostIsNoop(t *testing.T) {
+	b := newP2CLB([]string{"h"}).(*p2cLB)
+	finish := b.begin("missing", funnelApi.ZF)
+	// 不应该 panic，也不应该影响任何已知节点的统计
+	finish(false)
+	if got := b.scores["h"].inflight; got != 0 {
+		t.Fatalf("inflight of unrelated host = %d, want 0", got)
+	}
+}
@@ -0,0 +1,17 @@
+package metrics
+
+import (
+	"wejh-go/app/utils/circuitBreaker"
+	"wejh-go/config/api/funnelApi"
+)
+
+// init 把熔断器状态迁移事件桥接到 funnel_circuit_breaker_state 这个 gauge 上，
+// 把 LoadBalance 的 inflight 变化桥接到 funnel_inflight_requests 这个 gauge 上
+func init() {
+	circuitBreaker.OnStateChange(func(host string, loginType funnelApi.LoginType, from, to circuitBreaker.State) {
+		CircuitBreakerState.WithLabelValues(host, string(loginType)).Set(float64(to))
+	})
+	circuitBreaker.OnInflightChange(func(host string, loginType funnelApi.LoginType, delta int) {
+		Inflight.WithLabelValues(host, string(loginType)).Add(float64(delta))
+	})
+}
@@ -0,0 +1,65 @@
+// Package metrics 汇总 funnelServices 相关的 Prometheus 指标，通过 /metrics 暴露
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// RequestsTotal 按 host / loginType / funnel 响应码统计的请求数
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "funnel_requests_total",
+		Help: "Total funnel requests by host, login type and funnel response code.",
+	}, []string{"host", "login_type", "code"})
+
+	// RequestDuration 单节点请求耗时分布
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "funnel_request_duration_seconds",
+		Help:    "Latency of a single funnel host request.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"host", "login_type"})
+
+	// HedgeWins 对冲场景下，最终被采用结果的节点计数
+	HedgeWins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "funnel_hedge_wins_total",
+		Help: "Number of times a host's response won the hedge race.",
+	}, []string{"host", "login_type"})
+
+	// Inflight 当前在途请求数
+	Inflight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "funnel_inflight_requests",
+		Help: "In-flight funnel requests by host and login type.",
+	}, []string{"host", "login_type"})
+
+	// CircuitBreakerState 熔断器当前状态：0=closed 1=open 2=half_open
+	CircuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "funnel_circuit_breaker_state",
+		Help: "Circuit breaker state by host and login type (0=closed, 1=open, 2=half_open).",
+	}, []string{"host", "login_type"})
+
+	// CacheHits / CacheMisses 按资源类型统计 yxyServices 的 Redis 缓存命中情况
+	CacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yxy_cache_hits_total",
+		Help: "Cache hits in yxyServices by resource.",
+	}, []string{"resource"})
+
+	CacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yxy_cache_misses_total",
+		Help: "Cache misses in yxyServices by resource.",
+	}, []string{"resource"})
+)
+
+// Handler 返回标准的 Prometheus 抓取 handler，注册到 /metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// GinHandler 是 Handler 的 gin.HandlerFunc 包装，方便直接 router.GET("/metrics", metrics.GinHandler())
+func GinHandler() gin.HandlerFunc {
+	return gin.WrapH(Handler())
+}